@@ -0,0 +1,32 @@
+package configobservation
+
+import (
+	configlistersv1 "github.com/openshift/client-go/config/listers/config/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/openshift/library-go/pkg/operator/configobserver"
+)
+
+// Listers is the read-only surface handed to every config observer wired up
+// in configobservercontroller. Observers type-assert it down to the narrower
+// interface (e.g. featuregates.FeatureGateLister) they actually need.
+type Listers struct {
+	FeatureGateLister_ configlistersv1.FeatureGateLister
+
+	ResourceSync       configobserver.ResourceSyncer
+	PreRunCachesSynced []cache.InformerSynced
+}
+
+var _ configobserver.Listers = Listers{}
+
+func (l Listers) FeatureGateLister() configlistersv1.FeatureGateLister {
+	return l.FeatureGateLister_
+}
+
+func (l Listers) ResourceSyncer() configobserver.ResourceSyncer {
+	return l.ResourceSync
+}
+
+func (l Listers) PreRunHasSynced() []cache.InformerSynced {
+	return l.PreRunCachesSynced
+}
@@ -0,0 +1,378 @@
+package featuregates
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	configv1 "github.com/openshift/api/config/v1"
+	configlistersv1 "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/operator/configobserver"
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// FeatureGateLister allows an Observe* function to fetch the cluster-wide
+// FeatureGate resource.
+type FeatureGateLister interface {
+	FeatureGateLister() configlistersv1.FeatureGateLister
+}
+
+// DesiredVersionAccessor is implemented by whatever in this operator knows
+// which kube-controller-manager version is currently being rolled out. It is
+// used to pick the matching entry out of FeatureGate.status.featureGates[],
+// the same way library-go's FeatureGateAccess matches a status snapshot to
+// the operand version driving it.
+type DesiredVersionAccessor interface {
+	DesiredVersion() string
+}
+
+// NewObserveFeatureFlagsFunc returns an ObserveConfigFunc that fills in
+// --feature-gates for kube-controller-manager by resolving fg.Spec.FeatureSet
+// against the FeatureSets table compiled into this binary.
+func NewObserveFeatureFlagsFunc(knownFeatures sets.String, configPath []string) configobserver.ObserveConfigFunc {
+	return (&featureFlags{
+		allowAll:      len(knownFeatures) == 0,
+		knownFeatures: knownFeatures,
+		configPath:    configPath,
+	}).ObserveFeatureFlags
+}
+
+// NewObserveFeatureFlagsFromStatusFunc returns a FeatureGateStatusObserver
+// whose Observe func fills in --feature-gates the same way, but sources the enabled/disabled
+// names from FeatureGate.status.featureGates[] for the version returned by
+// versionAccessor.DesiredVersion(), instead of from the compiled-in
+// FeatureSets table. That table is baked into the operator binary, so a
+// cluster whose FeatureGate API is ahead of or behind the operator's
+// compiled version would otherwise resolve the wrong set; reading the
+// per-version status entry instead mirrors the migration cluster-storage-operator
+// made away from the compiled FeatureSets map.
+//
+// Until a status entry for the desired version is published, Observe
+// leaves the previously observed config untouched and returns no error,
+// and HasSynced reports false, so callers can block their sync loop on it rather
+// than guess at a feature set that doesn't yet exist.
+func NewObserveFeatureFlagsFromStatusFunc(knownFeatures sets.String, configPath []string, versionAccessor DesiredVersionAccessor) *FeatureGateStatusObserver {
+	f := &featureFlags{
+		allowAll:        len(knownFeatures) == 0,
+		knownFeatures:   knownFeatures,
+		configPath:      configPath,
+		versionAccessor: versionAccessor,
+	}
+	return &FeatureGateStatusObserver{
+		Observe:   f.ObserveFeatureFlagsFromStatus,
+		HasSynced: f.hasSynced,
+	}
+}
+
+// FeatureGateStatusObserver bundles the status-backed config observer
+// together with a readiness check so starter.go can hold the operator's sync
+// loop until the first status-based snapshot is observed.
+type FeatureGateStatusObserver struct {
+	Observe   configobserver.ObserveConfigFunc
+	HasSynced func() bool
+}
+
+type featureFlags struct {
+	allowAll      bool
+	knownFeatures sets.String
+	configPath    []string
+
+	// versionAccessor and synced are only used by ObserveFeatureFlagsFromStatus.
+	versionAccessor DesiredVersionAccessor
+	synced          int32
+}
+
+func (f *featureFlags) hasSynced() bool {
+	return atomic.LoadInt32(&f.synced) == 1
+}
+
+// ObserveFeatureFlags fills in --feature-gates for kube-controller-manager
+// from the FeatureSets table compiled into this binary.
+func (f *featureFlags) ObserveFeatureFlags(genericListers configobserver.Listers, recorder events.Recorder, existingConfig map[string]interface{}) (map[string]interface{}, []error) {
+	listers := genericListers.(FeatureGateLister)
+	errs := []error{}
+	prevObservedConfig := map[string]interface{}{}
+
+	currentConfigValue, _, err := unstructured.NestedStringSlice(existingConfig, f.configPath...)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(currentConfigValue) > 0 {
+		if err := unstructured.SetNestedStringSlice(prevObservedConfig, currentConfigValue, f.configPath...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	observedConfig := map[string]interface{}{}
+	configResource, err := listers.FeatureGateLister().Get("cluster")
+	// if we have no featuregate, then the installer and MCO probably still have way to reconcile certain custom resources
+	// we will assume that this means the same as default and hope for the best
+	if apierrors.IsNotFound(err) {
+		configResource = &configv1.FeatureGate{
+			Spec: configv1.FeatureGateSpec{
+				FeatureGateSelection: configv1.FeatureGateSelection{
+					FeatureSet: configv1.Default,
+				},
+			},
+		}
+	} else if err != nil {
+		errs = append(errs, err)
+		return prevObservedConfig, errs
+	}
+
+	newConfigValue, err := f.getFeatureNames(configResource, recorder)
+	if err != nil {
+		errs = append(errs, err)
+		return prevObservedConfig, errs
+	}
+
+	return f.writeFeatureGates(observedConfig, recorder, currentConfigValue, newConfigValue, errs)
+}
+
+// writeFeatureGates sorts newConfigValue for byte-identical output across
+// restarts, emits per-gate diff events against currentConfigValue, and
+// writes the result into observedConfig at f.configPath. Both
+// ObserveFeatureFlags and ObserveFeatureFlagsFromStatus funnel through this
+// so the ordering/diff-event contract holds regardless of which one a
+// caller wires up.
+func (f *featureFlags) writeFeatureGates(observedConfig map[string]interface{}, recorder events.Recorder, currentConfigValue, newConfigValue []string, errs []error) (map[string]interface{}, []error) {
+	// Sort so that an unchanged set of gates always produces a byte-identical
+	// slice, regardless of the order the gates were resolved in. Otherwise a
+	// plain operator restart can reorder --feature-gates and trigger a
+	// spurious static-pod revision.
+	sort.Strings(newConfigValue)
+
+	recordFeatureGateDiff(recorder, f.configPath, currentConfigValue, newConfigValue)
+
+	if err := unstructured.SetNestedStringSlice(observedConfig, newConfigValue, f.configPath...); err != nil {
+		recorder.Warningf("ObserveFeatureFlags", "Failed setting %v: %v", strings.Join(f.configPath, "."), err)
+		errs = append(errs, err)
+	}
+
+	return observedConfig, errs
+}
+
+// recordFeatureGateDiff emits one event per gate that was added, removed or
+// flipped between prevConfigValue and newConfigValue, instead of a single
+// event carrying the whole comma-joined list. Each formatted entry is
+// "name=true"/"name=false"; entries that don't parse are ignored for diffing
+// purposes but still take effect in newConfigValue.
+func recordFeatureGateDiff(recorder events.Recorder, configPath []string, prevConfigValue, newConfigValue []string) {
+	prev := parseFeatureGateValues(prevConfigValue)
+	next := parseFeatureGateValues(newConfigValue)
+
+	names := sets.NewString()
+	for name := range prev {
+		names.Insert(name)
+	}
+	for name := range next {
+		names.Insert(name)
+	}
+
+	path := strings.Join(configPath, ".")
+	for _, name := range names.List() {
+		prevValue, hadPrev := prev[name]
+		nextValue, hasNext := next[name]
+		switch {
+		case !hadPrev && hasNext && nextValue:
+			recorder.Eventf("FeatureGateEnabled", "%s: feature gate %s enabled", path, name)
+		case !hadPrev && hasNext && !nextValue:
+			recorder.Eventf("FeatureGateDisabled", "%s: feature gate %s disabled", path, name)
+		case hadPrev && !hasNext:
+			recorder.Eventf("FeatureGateDisabled", "%s: feature gate %s removed", path, name)
+		case hadPrev && hasNext && prevValue != nextValue:
+			recorder.Eventf("FeatureGateFlipped", "%s: feature gate %s flipped from %v to %v", path, name, prevValue, nextValue)
+		}
+	}
+}
+
+func parseFeatureGateValues(values []string) map[string]bool {
+	result := map[string]bool{}
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		result[parts[0]] = parts[1] == "true"
+	}
+	return result
+}
+
+func (f *featureFlags) getFeatureNames(fg *configv1.FeatureGate, recorder events.Recorder) ([]string, error) {
+	newConfigValue := []string{}
+	formatEnabledFunc := func(fs string) string {
+		return fmt.Sprintf("%s=true", fs)
+	}
+	formatDisabledFunc := func(fs string) string {
+		return fmt.Sprintf("%s=false", fs)
+	}
+
+	if fg.Spec.FeatureSet == configv1.CustomNoUpgrade {
+		return f.getCustomNoUpgradeFeatureNames(fg, recorder)
+	}
+
+	enabled, disabled, err := featureGateState(fg)
+	if err != nil {
+		return nil, err
+	}
+	for _, enable := range enabled.List() {
+		// only add whitelisted feature flags
+		if !f.allowAll && !f.knownFeatures.Has(enable) {
+			continue
+		}
+		newConfigValue = append(newConfigValue, formatEnabledFunc(enable))
+	}
+	for _, disable := range disabled.List() {
+		// only add whitelisted feature flags
+		if !f.allowAll && !f.knownFeatures.Has(disable) {
+			continue
+		}
+		newConfigValue = append(newConfigValue, formatDisabledFunc(disable))
+	}
+
+	return newConfigValue, nil
+}
+
+// getCustomNoUpgradeFeatureNames resolves CustomNoUpgrade.Enabled/Disabled
+// into the --feature-gates entries. Unlike the built-in FeatureSets path,
+// unknown names are a hard error rather than a silent drop (see
+// knownFeatureGates in starter.go for why). A name listed in both Enabled
+// and Disabled is treated as Disabled, since that's the safer reading of a
+// conflicting request.
+func (f *featureFlags) getCustomNoUpgradeFeatureNames(fg *configv1.FeatureGate, recorder events.Recorder) ([]string, error) {
+	custom := fg.Spec.FeatureGateSelection.CustomNoUpgrade
+	if custom == nil {
+		return nil, fmt.Errorf(".spec.featureGateSelection.CustomNoUpgrade is empty but the flag %s was set", configv1.CustomNoUpgrade)
+	}
+
+	enabled := sets.NewString(custom.Enabled...)
+	disabled := sets.NewString(custom.Disabled...)
+	if conflicting := enabled.Intersection(disabled); conflicting.Len() > 0 {
+		enabled = enabled.Difference(conflicting)
+		recorder.Warningf("FeatureGatesConflict", "Feature gate(s) %s are listed in both CustomNoUpgrade.Enabled and CustomNoUpgrade.Disabled; disabling", strings.Join(conflicting.List(), ", "))
+	}
+
+	if !f.allowAll {
+		unknown := enabled.Union(disabled).Difference(f.knownFeatures)
+		if unknown.Len() > 0 {
+			return nil, fmt.Errorf("CustomNoUpgrade references unknown feature gate(s): %s", strings.Join(unknown.List(), ", "))
+		}
+	}
+
+	newConfigValue := make([]string, 0, enabled.Len()+disabled.Len())
+	for _, name := range enabled.List() {
+		newConfigValue = append(newConfigValue, fmt.Sprintf("%s=true", name))
+	}
+	for _, name := range disabled.List() {
+		newConfigValue = append(newConfigValue, fmt.Sprintf("%s=false", name))
+	}
+	return newConfigValue, nil
+}
+
+// featureGateState resolves which gate names are enabled/disabled for fg,
+// via Spec.FeatureSet's compiled-in FeatureSets table, or via
+// Spec.FeatureGateSelection.CustomNoUpgrade when FeatureSet is
+// CustomNoUpgrade. It backs only the compiled-FeatureSets path
+// (ObserveFeatureFlags/getFeatureNames); the status-based observers resolve
+// gate state from FeatureGate.status instead, via statusFeatureGatesForVersion.
+func featureGateState(fg *configv1.FeatureGate) (enabled, disabled sets.String, err error) {
+	if fg.Spec.FeatureSet == configv1.CustomNoUpgrade {
+		if fg.Spec.FeatureGateSelection.CustomNoUpgrade == nil {
+			return nil, nil, fmt.Errorf(".spec.featureGateSelection.CustomNoUpgrade is empty but the flag %s was set", configv1.CustomNoUpgrade)
+		}
+		return sets.NewString(fg.Spec.FeatureGateSelection.CustomNoUpgrade.Enabled...),
+			sets.NewString(fg.Spec.FeatureGateSelection.CustomNoUpgrade.Disabled...),
+			nil
+	}
+
+	featureSet, ok := configv1.FeatureSets[fg.Spec.FeatureSet]
+	if !ok {
+		return nil, nil, fmt.Errorf(".spec.featureSet %q not found", fg.Spec.FeatureSet)
+	}
+	return sets.NewString(featureSet.Enabled...), sets.NewString(featureSet.Disabled...), nil
+}
+
+// ObserveFeatureFlagsFromStatus fills in --feature-gates for
+// kube-controller-manager by reading FeatureGate.status.featureGates[],
+// matching the entry whose Version equals f.versionAccessor.DesiredVersion().
+// Until that entry shows up it returns the previously observed config and no
+// error, leaving f.hasSynced() false so the caller can gate its sync loop.
+func (f *featureFlags) ObserveFeatureFlagsFromStatus(genericListers configobserver.Listers, recorder events.Recorder, existingConfig map[string]interface{}) (map[string]interface{}, []error) {
+	listers := genericListers.(FeatureGateLister)
+	errs := []error{}
+	prevObservedConfig := map[string]interface{}{}
+
+	currentConfigValue, _, err := unstructured.NestedStringSlice(existingConfig, f.configPath...)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(currentConfigValue) > 0 {
+		if err := unstructured.SetNestedStringSlice(prevObservedConfig, currentConfigValue, f.configPath...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	configResource, err := listers.FeatureGateLister().Get("cluster")
+	if apierrors.IsNotFound(err) {
+		// No FeatureGate yet: nothing to resolve against, wait for it.
+		return prevObservedConfig, errs
+	} else if err != nil {
+		errs = append(errs, err)
+		return prevObservedConfig, errs
+	}
+
+	desiredVersion := f.versionAccessor.DesiredVersion()
+	statusGates := statusFeatureGatesForVersion(configResource, desiredVersion)
+	if statusGates == nil {
+		// The featuregate-status controller hasn't published a snapshot for
+		// our version yet: keep the last known-good config and try again on
+		// the next resync rather than guess.
+		return prevObservedConfig, errs
+	}
+	atomic.StoreInt32(&f.synced, 1)
+
+	var unknown []string
+	newConfigValue := []string{}
+	for _, gate := range statusGates.Enabled {
+		if !f.allowAll && !f.knownFeatures.Has(string(gate.Name)) {
+			unknown = append(unknown, string(gate.Name))
+			continue
+		}
+		newConfigValue = append(newConfigValue, fmt.Sprintf("%s=true", gate.Name))
+	}
+	for _, gate := range statusGates.Disabled {
+		if !f.allowAll && !f.knownFeatures.Has(string(gate.Name)) {
+			unknown = append(unknown, string(gate.Name))
+			continue
+		}
+		newConfigValue = append(newConfigValue, fmt.Sprintf("%s=false", gate.Name))
+	}
+	if len(unknown) > 0 {
+		// See knownFeatureGates in starter.go for why an unknown name here
+		// degrades to the last known-good config instead of shipping it.
+		sort.Strings(unknown)
+		errs = append(errs, fmt.Errorf("FeatureGate status for version %q references unknown feature gate(s): %s", desiredVersion, strings.Join(unknown, ", ")))
+		return prevObservedConfig, errs
+	}
+
+	return f.writeFeatureGates(map[string]interface{}{}, recorder, currentConfigValue, newConfigValue, errs)
+}
+
+// statusFeatureGatesForVersion returns the entry in fg.Status.FeatureGates
+// whose Version matches desiredVersion, or nil if the featuregate-status
+// controller hasn't published a snapshot for it yet. Shared by every
+// observer that resolves gate state from status rather than the compiled
+// FeatureSets table, so they all agree on which snapshot is authoritative.
+func statusFeatureGatesForVersion(fg *configv1.FeatureGate, desiredVersion string) *configv1.FeatureGateDetails {
+	for i := range fg.Status.FeatureGates {
+		if fg.Status.FeatureGates[i].Version == desiredVersion {
+			return &fg.Status.FeatureGates[i]
+		}
+	}
+	return nil
+}
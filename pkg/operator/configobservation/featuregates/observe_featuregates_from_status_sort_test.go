@@ -0,0 +1,125 @@
+package featuregates
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	configv1 "github.com/openshift/api/config/v1"
+	configlistersv1 "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/operator/configobserver"
+)
+
+type fakeDesiredVersionAccessor struct {
+	version string
+}
+
+func (f fakeDesiredVersionAccessor) DesiredVersion() string { return f.version }
+
+// statusOnlyFeatureGateLister is a throwaway configlistersv1.FeatureGateLister
+// used only by the tests in this file; unlike the shared fakeFeatureGateLister
+// it always returns fg and never reports NotFound.
+type statusOnlyFeatureGateLister struct {
+	fg *configv1.FeatureGate
+}
+
+func (s statusOnlyFeatureGateLister) Get(name string) (*configv1.FeatureGate, error) {
+	return s.fg, nil
+}
+func (s statusOnlyFeatureGateLister) List(selector labels.Selector) ([]*configv1.FeatureGate, error) {
+	return []*configv1.FeatureGate{s.fg}, nil
+}
+
+type statusOnlyListers struct {
+	lister configlistersv1.FeatureGateLister
+}
+
+func (l statusOnlyListers) FeatureGateLister() configlistersv1.FeatureGateLister { return l.lister }
+func (l statusOnlyListers) ResourceSyncer() configobserver.ResourceSyncer        { return nil }
+func (l statusOnlyListers) PreRunHasSynced() []cache.InformerSynced              { return nil }
+
+func TestObserveFeatureFlagsFromStatusSortsAndDiffs(t *testing.T) {
+	featureGatesPath := []string{"extendedArguments", "feature-gates"}
+	fg := &configv1.FeatureGate{
+		Status: configv1.FeatureGateStatus{
+			FeatureGates: []configv1.FeatureGateDetails{
+				{
+					Version: "desired-version",
+					Enabled: []configv1.FeatureGateAttributes{
+						{Name: "Zeta"},
+						{Name: "Alpha"},
+					},
+				},
+			},
+		},
+	}
+
+	existing := map[string]interface{}{}
+	if err := unstructured.SetNestedStringSlice(existing, []string{"Alpha=true", "Zeta=true"}, featureGatesPath...); err != nil {
+		t.Fatalf("failed building existing config: %v", err)
+	}
+
+	f := &featureFlags{
+		allowAll:        true,
+		configPath:      featureGatesPath,
+		versionAccessor: fakeDesiredVersionAccessor{version: "desired-version"},
+	}
+	recorder := &fakeRecorder{}
+	observed, errs := f.ObserveFeatureFlagsFromStatus(statusOnlyListers{lister: statusOnlyFeatureGateLister{fg: fg}}, recorder, existing)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	actual, _, err := unstructured.NestedStringSlice(observed, featureGatesPath...)
+	if err != nil {
+		t.Fatalf("unexpected error reading observed config: %v", err)
+	}
+	want := []string{"Alpha=true", "Zeta=true"}
+	if len(actual) != len(want) || actual[0] != want[0] || actual[1] != want[1] {
+		t.Fatalf("expected sorted %v, got %v", want, actual)
+	}
+
+	// No change from the existing config: no diff-style events should fire.
+	if len(recorder.events) != 0 {
+		t.Fatalf("expected no events for an unchanged set of gates, got %v", recorder.events)
+	}
+}
+
+func TestObserveFeatureFlagsFromStatusEmitsDiffEvents(t *testing.T) {
+	featureGatesPath := []string{"extendedArguments", "feature-gates"}
+	fg := &configv1.FeatureGate{
+		Status: configv1.FeatureGateStatus{
+			FeatureGates: []configv1.FeatureGateDetails{
+				{
+					Version: "desired-version",
+					Enabled: []configv1.FeatureGateAttributes{
+						{Name: "Alpha"},
+						{Name: "NewGate"},
+					},
+				},
+			},
+		},
+	}
+
+	existing := map[string]interface{}{}
+	if err := unstructured.SetNestedStringSlice(existing, []string{"Alpha=true"}, featureGatesPath...); err != nil {
+		t.Fatalf("failed building existing config: %v", err)
+	}
+
+	f := &featureFlags{
+		allowAll:        true,
+		configPath:      featureGatesPath,
+		versionAccessor: fakeDesiredVersionAccessor{version: "desired-version"},
+	}
+	recorder := &fakeRecorder{}
+	_, errs := f.ObserveFeatureFlagsFromStatus(statusOnlyListers{lister: statusOnlyFeatureGateLister{fg: fg}}, recorder, existing)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	if got := recorder.reasons("FeatureGateEnabled"); len(got) != 1 || got[0] != "NewGate" {
+		t.Fatalf("expected a single FeatureGateEnabled event for NewGate, got %v", got)
+	}
+}
@@ -0,0 +1,144 @@
+package featuregates
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+func TestObserveFeatureFlagsFromStatus(t *testing.T) {
+	featureGatesPath := []string{"extendedArguments", "feature-gates"}
+	existingWithCurrent := func(current []string) map[string]interface{} {
+		existing := map[string]interface{}{}
+		if len(current) > 0 {
+			if err := unstructured.SetNestedStringSlice(existing, current, featureGatesPath...); err != nil {
+				t.Fatalf("failed building existing config: %v", err)
+			}
+		}
+		return existing
+	}
+
+	tests := []struct {
+		name             string
+		featureGate      *configv1.FeatureGate
+		knownFeatures    sets.String
+		existingConfig   []string
+		wantConfig       []string
+		wantSynced       bool
+		wantErr          bool
+		wantUnchangedCfg bool
+	}{
+		{
+			name:             "no FeatureGate found leaves config untouched and not synced",
+			featureGate:      nil,
+			existingConfig:   []string{"Foo=true"},
+			wantUnchangedCfg: true,
+		},
+		{
+			name: "no status entry for desired version leaves config untouched and not synced",
+			featureGate: &configv1.FeatureGate{
+				Status: configv1.FeatureGateStatus{
+					FeatureGates: []configv1.FeatureGateDetails{
+						{Version: "other-version"},
+					},
+				},
+			},
+			existingConfig:   []string{"Foo=true"},
+			wantUnchangedCfg: true,
+		},
+		{
+			name: "matching version entry produces gate strings and flips synced",
+			featureGate: &configv1.FeatureGate{
+				Status: configv1.FeatureGateStatus{
+					FeatureGates: []configv1.FeatureGateDetails{
+						{
+							Version: "desired-version",
+							Enabled: []configv1.FeatureGateAttributes{
+								{Name: "Foo"},
+							},
+							Disabled: []configv1.FeatureGateAttributes{
+								{Name: "Bar"},
+							},
+						},
+					},
+				},
+			},
+			wantConfig: []string{"Bar=false", "Foo=true"},
+			wantSynced: true,
+		},
+		{
+			name:          "whitelist rejects a status entry naming a gate outside knownFeatures",
+			knownFeatures: sets.NewString("Foo"),
+			featureGate: &configv1.FeatureGate{
+				Status: configv1.FeatureGateStatus{
+					FeatureGates: []configv1.FeatureGateDetails{
+						{
+							Version: "desired-version",
+							Enabled: []configv1.FeatureGateAttributes{
+								{Name: "Foo"},
+								{Name: "Baz"},
+							},
+						},
+					},
+				},
+			},
+			existingConfig: []string{"Foo=true"},
+			wantErr:        true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := &featureFlags{
+				allowAll:        len(test.knownFeatures) == 0,
+				knownFeatures:   test.knownFeatures,
+				configPath:      featureGatesPath,
+				versionAccessor: fakeDesiredVersionAccessor{version: "desired-version"},
+			}
+			listers := fakeListers{lister: fakeFeatureGateLister{fg: test.featureGate}}
+			existing := existingWithCurrent(test.existingConfig)
+
+			observed, errs := f.ObserveFeatureFlagsFromStatus(listers, &fakeRecorder{}, existing)
+
+			if test.wantErr {
+				if len(errs) == 0 {
+					t.Fatal("expected an error, got none")
+				}
+				actual, _, err := unstructured.NestedStringSlice(observed, featureGatesPath...)
+				if err != nil {
+					t.Fatalf("unexpected error reading observed config: %v", err)
+				}
+				if !sets.NewString(actual...).Equal(sets.NewString(test.existingConfig...)) {
+					t.Fatalf("expected degrade to previous config %v, got %v", test.existingConfig, actual)
+				}
+				return
+			}
+			if len(errs) > 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+
+			if f.hasSynced() != test.wantSynced {
+				t.Fatalf("hasSynced() = %v, want %v", f.hasSynced(), test.wantSynced)
+			}
+
+			actual, _, err := unstructured.NestedStringSlice(observed, featureGatesPath...)
+			if err != nil {
+				t.Fatalf("unexpected error reading observed config: %v", err)
+			}
+
+			if test.wantUnchangedCfg {
+				if !sets.NewString(actual...).Equal(sets.NewString(test.existingConfig...)) {
+					t.Fatalf("expected config to remain %v, got %v", test.existingConfig, actual)
+				}
+				return
+			}
+
+			if !sets.NewString(actual...).Equal(sets.NewString(test.wantConfig...)) {
+				t.Fatalf("expected %v, got %v", test.wantConfig, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package featuregates
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// fakeRecorder is a minimal events.Recorder that just remembers, per reason,
+// the names recordFeatureGateDiff reported - enough to assert on the diff
+// contract without pulling in a full eventstesting dependency.
+type fakeRecorder struct {
+	events []fakeEvent
+}
+
+type fakeEvent struct {
+	reason, name string
+}
+
+var _ events.Recorder = &fakeRecorder{}
+
+func (f *fakeRecorder) Eventf(reason, messageFmt string, args ...interface{}) {
+	f.events = append(f.events, fakeEvent{reason: reason, name: extractGateName(fmt.Sprintf(messageFmt, args...))})
+}
+func (f *fakeRecorder) Event(reason, message string) {
+	f.events = append(f.events, fakeEvent{reason: reason, name: extractGateName(message)})
+}
+func (f *fakeRecorder) Warning(reason, message string)                          {}
+func (f *fakeRecorder) Warningf(reason, messageFmt string, args ...interface{}) {}
+func (f *fakeRecorder) ForComponent(componentName string) events.Recorder       { return f }
+func (f *fakeRecorder) WithContext(ctx context.Context) events.Recorder         { return f }
+func (f *fakeRecorder) ComponentName() string                                   { return "test" }
+func (f *fakeRecorder) Shutdown()                                               {}
+
+func (f *fakeRecorder) reasons(reason string) []string {
+	var names []string
+	for _, e := range f.events {
+		if e.reason == reason {
+			names = append(names, e.name)
+		}
+	}
+	return names
+}
+
+// extractGateName pulls the gate name back out of the formatted message,
+// e.g. "extendedArguments.feature-gates: feature gate CSIMigration enabled"
+// -> "CSIMigration". Every recordFeatureGateDiff message puts it right after
+// "feature gate ".
+func extractGateName(message string) string {
+	const marker = "feature gate "
+	idx := strings.Index(message, marker)
+	if idx < 0 {
+		return ""
+	}
+	rest := message[idx+len(marker):]
+	if spaceIdx := strings.IndexByte(rest, ' '); spaceIdx >= 0 {
+		return rest[:spaceIdx]
+	}
+	return rest
+}
+
+func TestGetFeatureNames(t *testing.T) {
+	tests := []struct {
+		name          string
+		knownFeatures sets.String
+		featureGate   *configv1.FeatureGate
+		expected      []string
+		expectErr     bool
+	}{
+		{
+			name:        "default featureset",
+			featureGate: featureGateWithSet(configv1.Default),
+			expected:    append(append([]string{}, enabledStrings(configv1.FeatureSets[configv1.Default].Enabled)...), disabledStrings(configv1.FeatureSets[configv1.Default].Disabled)...),
+		},
+		{
+			name:        "techpreview featureset",
+			featureGate: featureGateWithSet(configv1.TechPreviewNoUpgrade),
+			expected:    append(append([]string{}, enabledStrings(configv1.FeatureSets[configv1.TechPreviewNoUpgrade].Enabled)...), disabledStrings(configv1.FeatureSets[configv1.TechPreviewNoUpgrade].Disabled)...),
+		},
+		{
+			name: "custom no upgrade",
+			featureGate: &configv1.FeatureGate{
+				Spec: configv1.FeatureGateSpec{
+					FeatureGateSelection: configv1.FeatureGateSelection{
+						FeatureSet: configv1.CustomNoUpgrade,
+						CustomNoUpgrade: &configv1.CustomFeatureGates{
+							Enabled:  []string{"FooGate"},
+							Disabled: []string{"BarGate"},
+						},
+					},
+				},
+			},
+			expected: []string{"FooGate=true", "BarGate=false"},
+		},
+		{
+			name:          "custom no upgrade rejects unknown names against whitelist",
+			knownFeatures: sets.NewString("FooGate"),
+			featureGate: &configv1.FeatureGate{
+				Spec: configv1.FeatureGateSpec{
+					FeatureGateSelection: configv1.FeatureGateSelection{
+						FeatureSet: configv1.CustomNoUpgrade,
+						CustomNoUpgrade: &configv1.CustomFeatureGates{
+							Enabled:  []string{"FooGate", "BarGate"},
+							Disabled: []string{},
+						},
+					},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "custom no upgrade empty enabled/disabled",
+			featureGate: &configv1.FeatureGate{
+				Spec: configv1.FeatureGateSpec{
+					FeatureGateSelection: configv1.FeatureGateSelection{
+						FeatureSet:      configv1.CustomNoUpgrade,
+						CustomNoUpgrade: &configv1.CustomFeatureGates{},
+					},
+				},
+			},
+			expected: []string{},
+		},
+		{
+			name: "custom no upgrade conflict prefers disabled",
+			featureGate: &configv1.FeatureGate{
+				Spec: configv1.FeatureGateSpec{
+					FeatureGateSelection: configv1.FeatureGateSelection{
+						FeatureSet: configv1.CustomNoUpgrade,
+						CustomNoUpgrade: &configv1.CustomFeatureGates{
+							Enabled:  []string{"FooGate"},
+							Disabled: []string{"FooGate"},
+						},
+					},
+				},
+			},
+			expected: []string{"FooGate=false"},
+		},
+		{
+			name: "custom no upgrade missing selection errors",
+			featureGate: &configv1.FeatureGate{
+				Spec: configv1.FeatureGateSpec{
+					FeatureGateSelection: configv1.FeatureGateSelection{
+						FeatureSet: configv1.CustomNoUpgrade,
+					},
+				},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := &featureFlags{
+				allowAll:      len(test.knownFeatures) == 0,
+				knownFeatures: test.knownFeatures,
+			}
+			actual, err := f.getFeatureNames(test.featureGate, &fakeRecorder{})
+			if test.expectErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !sets.NewString(actual...).Equal(sets.NewString(test.expected...)) {
+				t.Fatalf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRecordFeatureGateDiff(t *testing.T) {
+	tests := []struct {
+		name         string
+		prev         []string
+		next         []string
+		wantEnabled  []string
+		wantDisabled []string
+		wantFlipped  []string
+	}{
+		{
+			name:        "default to techpreview enables new gates",
+			prev:        []string{"APIPriorityAndFairness=true"},
+			next:        []string{"APIPriorityAndFairness=true", "CSIMigration=true"},
+			wantEnabled: []string{"CSIMigration"},
+		},
+		{
+			name:         "techpreview to default removes gates",
+			prev:         []string{"APIPriorityAndFairness=true", "CSIMigration=true"},
+			next:         []string{"APIPriorityAndFairness=true"},
+			wantDisabled: []string{"CSIMigration"},
+		},
+		{
+			name:        "flip from default to customnoupgrade",
+			prev:        []string{"CSIMigration=false"},
+			next:        []string{"CSIMigration=true"},
+			wantFlipped: []string{"CSIMigration"},
+		},
+		{
+			name: "no-op emits nothing",
+			prev: []string{"CSIMigration=true"},
+			next: []string{"CSIMigration=true"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := &fakeRecorder{}
+			recordFeatureGateDiff(recorder, []string{"extendedArguments", "feature-gates"}, test.prev, test.next)
+
+			if !sets.NewString(recorder.reasons("FeatureGateEnabled")...).Equal(sets.NewString(test.wantEnabled...)) {
+				t.Errorf("enabled: got %v, want %v", recorder.reasons("FeatureGateEnabled"), test.wantEnabled)
+			}
+			if !sets.NewString(recorder.reasons("FeatureGateDisabled")...).Equal(sets.NewString(test.wantDisabled...)) {
+				t.Errorf("disabled: got %v, want %v", recorder.reasons("FeatureGateDisabled"), test.wantDisabled)
+			}
+			if !sets.NewString(recorder.reasons("FeatureGateFlipped")...).Equal(sets.NewString(test.wantFlipped...)) {
+				t.Errorf("flipped: got %v, want %v", recorder.reasons("FeatureGateFlipped"), test.wantFlipped)
+			}
+		})
+	}
+}
+
+func featureGateWithSet(fs configv1.FeatureSet) *configv1.FeatureGate {
+	return &configv1.FeatureGate{
+		Spec: configv1.FeatureGateSpec{
+			FeatureGateSelection: configv1.FeatureGateSelection{
+				FeatureSet: fs,
+			},
+		},
+	}
+}
+
+func enabledStrings(names []string) []string {
+	out := make([]string, 0, len(names))
+	for _, n := range names {
+		out = append(out, n+"=true")
+	}
+	return out
+}
+
+func disabledStrings(names []string) []string {
+	out := make([]string, 0, len(names))
+	for _, n := range names {
+		out = append(out, n+"=false")
+	}
+	return out
+}
@@ -0,0 +1,180 @@
+package featuregates
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/library-go/pkg/operator/configobserver"
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// FlagMutation describes how a flag's string-slice value in the observed
+// config should react to a feature gate flipping. ConfigPath points at the
+// flag's location (e.g. ["extendedArguments", "controllers"]);
+// AddWhenEnabled/RemoveWhenDisabled list the entries to splice in or out
+// when the gate is enabled, and the reverse when it's disabled.
+type FlagMutation struct {
+	ConfigPath         []string
+	AddWhenEnabled     []string
+	RemoveWhenDisabled []string
+}
+
+// NewObserveRuntimeConfigFunc returns an ObserveConfigFunc that keeps
+// declarative flag lists - most notably --controllers, but anything shaped
+// like it, e.g. --external-cloud-volume-plugin - in lockstep with the named
+// feature gates: mutations[gate] is (re)applied to the config whenever
+// gate's enabled state differs from what the flag's current value already
+// reflects. This mirrors the pattern cluster-kube-apiserver-operator uses to
+// keep --runtime-config aligned with feature gates that aren't yet
+// unconditionally on.
+//
+// Multiple gates are allowed to target the same ConfigPath (e.g. two
+// feature gates both append a controller name to --controllers); they are
+// folded together in a stable order rather than the last one processed
+// winning.
+//
+// Gate state is resolved from FeatureGate.status.featureGates[] for
+// versionAccessor.DesiredVersion(), the same source ObserveFeatureFlagsFromStatus
+// uses for --feature-gates, so --controllers can't drift out of lockstep with
+// the --feature-gates value that's actually shipped.
+func NewObserveRuntimeConfigFunc(mutations map[string]FlagMutation, versionAccessor DesiredVersionAccessor) configobserver.ObserveConfigFunc {
+	return (&runtimeConfig{mutations: mutations, versionAccessor: versionAccessor}).ObserveRuntimeConfig
+}
+
+type runtimeConfig struct {
+	mutations       map[string]FlagMutation
+	versionAccessor DesiredVersionAccessor
+}
+
+// mutationGroup is every FlagMutation that targets the same ConfigPath,
+// keyed by the feature gate that contributed it, in a stable (sorted by
+// gate name) order.
+type mutationGroup struct {
+	configPath []string
+	gates      []string
+	mutations  []FlagMutation
+}
+
+func (r *runtimeConfig) groupedByPath() []mutationGroup {
+	groupsByKey := map[string]*mutationGroup{}
+	var keys []string
+	for gate, mutation := range r.mutations {
+		key := strings.Join(mutation.ConfigPath, "\x00")
+		group, ok := groupsByKey[key]
+		if !ok {
+			group = &mutationGroup{configPath: mutation.ConfigPath}
+			groupsByKey[key] = group
+			keys = append(keys, key)
+		}
+		group.gates = append(group.gates, gate)
+		group.mutations = append(group.mutations, mutation)
+	}
+
+	sort.Strings(keys)
+	groups := make([]mutationGroup, 0, len(keys))
+	for _, key := range keys {
+		group := groupsByKey[key]
+		sortParallel(group.gates, group.mutations)
+		groups = append(groups, *group)
+	}
+	return groups
+}
+
+// sortParallel sorts gates alphabetically and permutes mutations the same
+// way, so grouping a map into a deterministic order doesn't depend on the
+// order map iteration happened to hand mutations to groupedByPath in.
+func sortParallel(gates []string, mutations []FlagMutation) {
+	for i := 1; i < len(gates); i++ {
+		for j := i; j > 0 && gates[j-1] > gates[j]; j-- {
+			gates[j-1], gates[j] = gates[j], gates[j-1]
+			mutations[j-1], mutations[j] = mutations[j], mutations[j-1]
+		}
+	}
+}
+
+func (r *runtimeConfig) ObserveRuntimeConfig(genericListers configobserver.Listers, recorder events.Recorder, existingConfig map[string]interface{}) (map[string]interface{}, []error) {
+	listers := genericListers.(FeatureGateLister)
+	errs := []error{}
+	groups := r.groupedByPath()
+
+	prevObservedConfig := map[string]interface{}{}
+	for _, group := range groups {
+		currentValue, _, err := unstructured.NestedStringSlice(existingConfig, group.configPath...)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(currentValue) > 0 {
+			if err := unstructured.SetNestedStringSlice(prevObservedConfig, currentValue, group.configPath...); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return prevObservedConfig, errs
+	}
+
+	featureGate, err := listers.FeatureGateLister().Get("cluster")
+	if apierrors.IsNotFound(err) {
+		return prevObservedConfig, errs
+	} else if err != nil {
+		errs = append(errs, err)
+		return prevObservedConfig, errs
+	}
+
+	desiredVersion := r.versionAccessor.DesiredVersion()
+	statusGates := statusFeatureGatesForVersion(featureGate, desiredVersion)
+	if statusGates == nil {
+		// No status snapshot for our version yet: keep the last known-good
+		// config rather than guess, same as ObserveFeatureFlagsFromStatus.
+		return prevObservedConfig, errs
+	}
+
+	enabled := sets.NewString()
+	for _, gate := range statusGates.Enabled {
+		enabled.Insert(string(gate.Name))
+	}
+
+	observedConfig := map[string]interface{}{}
+	for _, group := range groups {
+		currentValue, _, err := unstructured.NestedStringSlice(existingConfig, group.configPath...)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		newValue := currentValue
+		for i, gate := range group.gates {
+			newValue = applyFlagMutation(newValue, group.mutations[i], enabled.Has(gate))
+		}
+
+		if !reflect.DeepEqual(currentValue, newValue) {
+			recorder.Eventf("ObserveRuntimeConfigUpdated", "Updated %s to [%s] because feature gate(s) %s changed",
+				strings.Join(group.configPath, "."), strings.Join(newValue, ","), strings.Join(group.gates, ","))
+		}
+		if err := unstructured.SetNestedStringSlice(observedConfig, newValue, group.configPath...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return observedConfig, errs
+}
+
+// applyFlagMutation folds mutation into current, using a set so repeated
+// observations of the same gate state are idempotent and produce no churn.
+func applyFlagMutation(current []string, mutation FlagMutation, gateEnabled bool) []string {
+	want := sets.NewString(current...)
+	if gateEnabled {
+		want.Insert(mutation.AddWhenEnabled...)
+		want.Delete(mutation.RemoveWhenDisabled...)
+	} else {
+		want.Delete(mutation.AddWhenEnabled...)
+		want.Insert(mutation.RemoveWhenDisabled...)
+	}
+	return want.List()
+}
@@ -0,0 +1,153 @@
+package featuregates
+
+import (
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/client-go/tools/cache"
+
+	configv1 "github.com/openshift/api/config/v1"
+	configlistersv1 "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/operator/configobserver"
+)
+
+// fakeFeatureGateLister is a minimal configlistersv1.FeatureGateLister
+// backed by a single in-memory FeatureGate, named "cluster" like the real
+// lister would return. A nil fg simulates the FeatureGate not existing yet.
+type fakeFeatureGateLister struct {
+	fg *configv1.FeatureGate
+}
+
+var _ configlistersv1.FeatureGateLister = fakeFeatureGateLister{}
+
+func (f fakeFeatureGateLister) Get(name string) (*configv1.FeatureGate, error) {
+	if f.fg == nil {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: configv1.GroupName, Resource: "featuregates"}, name)
+	}
+	return f.fg, nil
+}
+func (f fakeFeatureGateLister) List(selector labels.Selector) ([]*configv1.FeatureGate, error) {
+	return []*configv1.FeatureGate{f.fg}, nil
+}
+
+// fakeListers satisfies configobserver.Listers (the type every ObserveConfigFunc
+// receives) and the local FeatureGateLister interface every observer in this
+// package type-asserts it down to.
+type fakeListers struct {
+	lister configlistersv1.FeatureGateLister
+}
+
+var _ configobserver.Listers = fakeListers{}
+
+func (f fakeListers) FeatureGateLister() configlistersv1.FeatureGateLister { return f.lister }
+func (f fakeListers) ResourceSyncer() configobserver.ResourceSyncer        { return nil }
+func (f fakeListers) PreRunHasSynced() []cache.InformerSynced              { return nil }
+
+// statusFeatureGate builds a FeatureGate whose status carries a single
+// per-version snapshot, the shape ObserveRuntimeConfig and
+// ObserveFeatureFlagsFromStatus both resolve gate state from.
+func statusFeatureGate(version string, enabled ...string) *configv1.FeatureGate {
+	fg := &configv1.FeatureGate{
+		Status: configv1.FeatureGateStatus{
+			FeatureGates: []configv1.FeatureGateDetails{
+				{Version: version},
+			},
+		},
+	}
+	for _, name := range enabled {
+		fg.Status.FeatureGates[0].Enabled = append(fg.Status.FeatureGates[0].Enabled, configv1.FeatureGateAttributes{Name: configv1.FeatureGateName(name)})
+	}
+	return fg
+}
+
+func TestObserveRuntimeConfigSharedConfigPath(t *testing.T) {
+	controllersPath := []string{"extendedArguments", "controllers"}
+	mutations := map[string]FlagMutation{
+		"GateA": {
+			ConfigPath:     controllersPath,
+			AddWhenEnabled: []string{"-controller-a"},
+		},
+		"GateB": {
+			ConfigPath:     controllersPath,
+			AddWhenEnabled: []string{"-controller-b"},
+		},
+	}
+
+	fg := statusFeatureGate("desired-version", "GateA", "GateB")
+
+	r := &runtimeConfig{mutations: mutations, versionAccessor: fakeDesiredVersionAccessor{version: "desired-version"}}
+	listers := fakeListers{lister: fakeFeatureGateLister{fg: fg}}
+	observed, errs := r.ObserveRuntimeConfig(listers, &fakeRecorder{}, map[string]interface{}{})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	actual, _, err := unstructured.NestedStringSlice(observed, controllersPath...)
+	if err != nil {
+		t.Fatalf("unexpected error reading observed config: %v", err)
+	}
+
+	want := sets.NewString("-controller-a", "-controller-b")
+	if !sets.NewString(actual...).Equal(want) {
+		t.Fatalf("expected both gates' mutations to be present in a single merge, got %v", actual)
+	}
+}
+
+func TestObserveRuntimeConfigRemoveWhenDisabled(t *testing.T) {
+	controllersPath := []string{"extendedArguments", "controllers"}
+	mutations := map[string]FlagMutation{
+		"GateA": {
+			ConfigPath:         controllersPath,
+			RemoveWhenDisabled: []string{"-controller-a"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		gateEnabled bool
+		want        sets.String
+	}{
+		{
+			name:        "gate disabled inserts RemoveWhenDisabled entries",
+			gateEnabled: false,
+			want:        sets.NewString("-controller-a"),
+		},
+		{
+			name:        "gate enabled prunes RemoveWhenDisabled entries",
+			gateEnabled: true,
+			want:        sets.NewString(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var fg *configv1.FeatureGate
+			if test.gateEnabled {
+				fg = statusFeatureGate("desired-version", "GateA")
+			} else {
+				fg = statusFeatureGate("desired-version")
+			}
+
+			r := &runtimeConfig{mutations: mutations, versionAccessor: fakeDesiredVersionAccessor{version: "desired-version"}}
+			listers := fakeListers{lister: fakeFeatureGateLister{fg: fg}}
+			observed, errs := r.ObserveRuntimeConfig(listers, &fakeRecorder{}, map[string]interface{}{})
+			if len(errs) > 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+
+			actual, _, err := unstructured.NestedStringSlice(observed, controllersPath...)
+			if err != nil {
+				t.Fatalf("unexpected error reading observed config: %v", err)
+			}
+
+			if !sets.NewString(actual...).Equal(test.want) {
+				t.Fatalf("expected %v, got %v", test.want.List(), actual)
+			}
+		})
+	}
+}
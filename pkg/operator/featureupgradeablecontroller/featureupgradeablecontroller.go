@@ -0,0 +1,85 @@
+package featureupgradeablecontroller
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	configv1informers "github.com/openshift/client-go/config/informers/externalversions"
+	configlistersv1 "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/factory"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+// upgradeableFeatureSets is the allow-list of FeatureSets that do not block
+// an upgrade. Anything else (TechPreviewNoUpgrade, CustomNoUpgrade) opts the
+// cluster administrator into gates that haven't been vetted for upgrade
+// safety, so the operator must refuse to claim Upgradeable=True while one of
+// them is active.
+var upgradeableFeatureSets = sets.NewString("", string(configv1.LatencySensitive))
+
+// FeatureUpgradeableController sets the operator's Upgradeable condition to
+// False whenever the cluster has opted into a non-default, non-vetted
+// FeatureSet, so that the CVO refuses to start an upgrade while custom or
+// tech-preview feature gates are in play.
+type FeatureUpgradeableController struct {
+	operatorClient    v1helpers.OperatorClient
+	featureGateLister configlistersv1.FeatureGateLister
+}
+
+func NewFeatureUpgradeableController(
+	operatorClient v1helpers.OperatorClient,
+	configInformer configv1informers.SharedInformerFactory,
+	recorder events.Recorder,
+) factory.Controller {
+	c := &FeatureUpgradeableController{
+		operatorClient:    operatorClient,
+		featureGateLister: configInformer.Config().V1().FeatureGates().Lister(),
+	}
+
+	return factory.New().
+		WithInformers(
+			operatorClient.Informer(),
+			configInformer.Config().V1().FeatureGates().Informer(),
+		).
+		WithSync(c.sync).
+		ToController("FeatureUpgradeableController", recorder)
+}
+
+func (c *FeatureUpgradeableController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	featureGate, err := c.featureGateLister.Get("cluster")
+	if apierrors.IsNotFound(err) {
+		return c.updateUpgradeable(ctx, operatorv1.ConditionTrue, "", "")
+	}
+	if err != nil {
+		return err
+	}
+
+	featureSet := featureGate.Spec.FeatureSet
+	if upgradeableFeatureSets.Has(string(featureSet)) {
+		return c.updateUpgradeable(ctx, operatorv1.ConditionTrue, "", "")
+	}
+
+	return c.updateUpgradeable(
+		ctx,
+		operatorv1.ConditionFalse,
+		fmt.Sprintf("FeatureGates_RestrictedFeatureGates_%s", featureSet),
+		fmt.Sprintf("Cluster has enabled FeatureSet %q which is not part of the supported upgrade path", featureSet),
+	)
+}
+
+func (c *FeatureUpgradeableController) updateUpgradeable(ctx context.Context, status operatorv1.ConditionStatus, reason, message string) error {
+	cond := operatorv1.OperatorCondition{
+		Type:    "FeatureGatesUpgradeable",
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+	_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+	return err
+}
@@ -0,0 +1,103 @@
+package operator
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+
+	configv1informers "github.com/openshift/client-go/config/informers/externalversions"
+	"github.com/openshift/library-go/pkg/controller/controllercmd"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+
+	operatorconfigobservation "github.com/openshift/cluster-kube-controller-manager-operator/pkg/operator/configobservation"
+	"github.com/openshift/cluster-kube-controller-manager-operator/pkg/operator/configobservation/configobservercontroller"
+	"github.com/openshift/cluster-kube-controller-manager-operator/pkg/operator/configobservation/featuregates"
+	"github.com/openshift/cluster-kube-controller-manager-operator/pkg/operator/featureupgradeablecontroller"
+)
+
+// knownFeatureGates lists every feature gate kube-controller-manager
+// understands. Anything outside this set is rejected rather than handed to
+// the kube-controller-manager binary, which would otherwise crash-loop on an
+// unrecognized --feature-gates entry - this is what guards against a typo
+// like "ValidatingAdmissionPolciy" reaching the static pod.
+var knownFeatureGates = sets.NewString(
+	"APIPriorityAndFairness",
+	"RetroactiveDefaultStorageClass",
+	"OpenShiftPodSecurityAdmission",
+	"ValidatingAdmissionPolicy",
+	"CSIDriverSharedResource",
+	"ExternalCloudProvider",
+)
+
+// featureGatesConfigPath is where the resolved --feature-gates entries land
+// in the observed KubeControllerManagerConfig.
+var featureGatesConfigPath = []string{"extendedArguments", "feature-gates"}
+
+// runtimeConfigMutations keeps flags that aren't themselves feature gates in
+// lockstep with the gates that require them, so a gated controller doesn't
+// flip on in kube-controller-manager before --controllers admits it (or stay
+// enabled after the gate that required it is gone).
+var runtimeConfigMutations = map[string]featuregates.FlagMutation{
+	"ExternalCloudProvider": {
+		ConfigPath:     []string{"extendedArguments", "controllers"},
+		AddWhenEnabled: []string{"-cloud-node-lifecycle-controller"},
+	},
+}
+
+func RunOperator(ctx context.Context, controllerContext *controllercmd.ControllerContext) error {
+	kubeInformersForNamespaces := v1helpers.NewKubeInformersForNamespaces(nil)
+	configInformers := configv1informers.NewSharedInformerFactory(nil, 10*time.Minute)
+
+	operatorClient, err := newOperatorClient(controllerContext)
+	if err != nil {
+		return err
+	}
+
+	// Resolves --feature-gates from FeatureGate.status.featureGates[] rather
+	// than from the FeatureSets table compiled into this binary, so the
+	// operator stays correct against a FeatureGate API ahead of or behind
+	// its own compiled version. HasSynced gates the rest of the operator
+	// until the first status-based snapshot for our version is observed.
+	statusObserver := featuregates.NewObserveFeatureFlagsFromStatusFunc(
+		knownFeatureGates,
+		featureGatesConfigPath,
+		operatorClient,
+	)
+
+	runtimeConfigObserver := featuregates.NewObserveRuntimeConfigFunc(runtimeConfigMutations, operatorClient)
+
+	configObserver := configobservercontroller.NewConfigObserver(
+		operatorClient,
+		operatorconfigobservation.Listers{
+			FeatureGateLister_: configInformers.Config().V1().FeatureGates().Lister(),
+			PreRunCachesSynced: []cache.InformerSynced{
+				configInformers.Config().V1().FeatureGates().Informer().HasSynced,
+				statusObserver.HasSynced,
+			},
+		},
+		controllerContext.EventRecorder,
+		statusObserver.Observe,
+		runtimeConfigObserver,
+	)
+
+	featureUpgradeableController := featureupgradeablecontroller.NewFeatureUpgradeableController(
+		operatorClient,
+		configInformers,
+		controllerContext.EventRecorder,
+	)
+
+	for _, informer := range []interface{ Start(stopCh <-chan struct{}) }{
+		kubeInformersForNamespaces,
+		configInformers,
+	} {
+		informer.Start(ctx.Done())
+	}
+
+	go configObserver.Run(ctx, 1)
+	go featureUpgradeableController.Run(ctx, 1)
+
+	<-ctx.Done()
+	return nil
+}